@@ -0,0 +1,30 @@
+package memorystore
+
+import "context"
+
+// Cipher provides envelope encryption for session metadata. Implementations encrypt
+// with their current key (identified by KeyID) and must be able to decrypt ciphertext
+// produced under any key ID they're still configured to know about, so callers can keep
+// reading old rows across a key rotation.
+type Cipher interface {
+	// KeyID identifies the key this Cipher currently encrypts with. It's stored
+	// alongside each ciphertext so a later Decrypt, possibly by a different Cipher
+	// instance after rotation, knows which key to use.
+	KeyID() string
+	// Encrypt encrypts plaintext, binding aad (the session ID) as additional
+	// authenticated data so a ciphertext can't be copied onto a different session.
+	Encrypt(ctx context.Context, plaintext []byte, aad []byte) (nonce, ciphertext []byte, err error)
+	// Decrypt reverses Encrypt. keyID selects which underlying key to use, so a Cipher
+	// holding multiple generations of key material can decrypt rows written before the
+	// most recent rotation.
+	Decrypt(ctx context.Context, keyID string, nonce, ciphertext, aad []byte) ([]byte, error)
+}
+
+// EncryptedMetadata is what's stored in PgSession.MetadataEnc: the output of a Cipher,
+// plus the key ID it was encrypted under so a later Decrypt (or a rotation job) knows
+// which key to use.
+type EncryptedMetadata struct {
+	KeyID      string `json:"key_id"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
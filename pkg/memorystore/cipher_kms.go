@@ -0,0 +1,108 @@
+package memorystore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// AWSKMSCipher is a Cipher that envelopes each plaintext under a fresh, KMS-generated
+// AES-256 data key rather than calling kms.Encrypt directly on the plaintext itself: KMS's
+// Encrypt API caps symmetric-key plaintext at 4096 bytes, which session metadata JSON can
+// easily exceed. The data key is used once, locally, with AES-GCM, and its KMS-wrapped
+// ciphertext is stored alongside the result so Decrypt can unwrap it through KMS again.
+// keyID is the full KMS key ARN or alias.
+type AWSKMSCipher struct {
+	client *kms.Client
+	keyID  string
+}
+
+// NewAWSKMSCipher builds an AWSKMSCipher that encrypts with the given KMS key ID
+// (ARN or alias).
+func NewAWSKMSCipher(client *kms.Client, keyID string) *AWSKMSCipher {
+	return &AWSKMSCipher{client: client, keyID: keyID}
+}
+
+func (c *AWSKMSCipher) KeyID() string {
+	return c.keyID
+}
+
+// awsEnvelope is what AWSKMSCipher packs into the Cipher interface's opaque nonce: the
+// KMS-wrapped data key plus the GCM nonce used with it. EncryptedMetadata has no field of
+// its own for a wrapped data key, so it travels inside the generic nonce slot instead.
+type awsEnvelope struct {
+	WrappedKey []byte `json:"wrapped_key"`
+	GCMNonce   []byte `json:"gcm_nonce"`
+}
+
+func (c *AWSKMSCipher) Encrypt(ctx context.Context, plaintext, aad []byte) ([]byte, []byte, error) {
+	dataKey, err := c.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(c.keyID),
+		KeySpec:           types.DataKeySpecAes256,
+		EncryptionContext: map[string]string{"aad": string(aad)},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("kms generate data key failed: %w", err)
+	}
+
+	aead, err := newAESGCM(dataKey.Plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build AES-GCM from data key: %w", err)
+	}
+
+	gcmNonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, gcmNonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	ciphertext := aead.Seal(nil, gcmNonce, plaintext, aad)
+
+	envelope, err := json.Marshal(awsEnvelope{WrappedKey: dataKey.CiphertextBlob, GCMNonce: gcmNonce})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	return envelope, ciphertext, nil
+}
+
+func (c *AWSKMSCipher) Decrypt(ctx context.Context, keyID string, nonce, ciphertext, aad []byte) ([]byte, error) {
+	var envelope awsEnvelope
+	if err := json.Unmarshal(nonce, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+
+	dataKey, err := c.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(keyID),
+		CiphertextBlob:    envelope.WrappedKey,
+		EncryptionContext: map[string]string{"aad": string(aad)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms decrypt of data key failed: %w", err)
+	}
+
+	aead, err := newAESGCM(dataKey.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build AES-GCM from data key: %w", err)
+	}
+	plaintext, err := aead.Open(nil, envelope.GCMNonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// newAESGCM builds an AES-GCM AEAD from a raw key, shared by AWSKMSCipher's local
+// data-key encryption step.
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,96 @@
+package memorystore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// LocalAESGCMCipher is a Cipher backed by an AES-256-GCM key loaded from an environment
+// variable, for deployments without a KMS; keys are not read from files. It supports decrypting under
+// multiple key IDs at once (keyed by a short fingerprint of each key) so a rotation can
+// keep old rows readable while new writes use the newest key.
+type LocalAESGCMCipher struct {
+	activeKeyID string
+	keys        map[string]cipher.AEAD
+}
+
+// NewLocalAESGCMCipherFromEnv builds a LocalAESGCMCipher whose active key is the 32-byte
+// hex-encoded value of the named environment variable. prevEnvVars, if given, name
+// environment variables holding prior keys that should remain decryptable.
+func NewLocalAESGCMCipherFromEnv(envVar string, prevEnvVars ...string) (*LocalAESGCMCipher, error) {
+	c := &LocalAESGCMCipher{keys: make(map[string]cipher.AEAD)}
+
+	activeKeyID, err := c.addKeyFromHex(os.Getenv(envVar))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load active key from %s: %w", envVar, err)
+	}
+	c.activeKeyID = activeKeyID
+
+	for _, ev := range prevEnvVars {
+		if _, err := c.addKeyFromHex(os.Getenv(ev)); err != nil {
+			return nil, fmt.Errorf("failed to load previous key from %s: %w", ev, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *LocalAESGCMCipher) addKeyFromHex(hexKey string) (string, error) {
+	raw, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return "", fmt.Errorf("key is not valid hex: %w", err)
+	}
+	if len(raw) != 32 {
+		return "", fmt.Errorf("key must be 32 bytes for AES-256, got %d", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to build AES cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("failed to build GCM: %w", err)
+	}
+	keyID := fingerprintKey(raw)
+	c.keys[keyID] = aead
+	return keyID, nil
+}
+
+func (c *LocalAESGCMCipher) KeyID() string {
+	return c.activeKeyID
+}
+
+func (c *LocalAESGCMCipher) Encrypt(_ context.Context, plaintext, aad []byte) ([]byte, []byte, error) {
+	aead := c.keys[c.activeKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	ciphertext := aead.Seal(nil, nonce, plaintext, aad)
+	return nonce, ciphertext, nil
+}
+
+func (c *LocalAESGCMCipher) Decrypt(_ context.Context, keyID string, nonce, ciphertext, aad []byte) ([]byte, error) {
+	aead, ok := c.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("no local key material for key id %q", keyID)
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// fingerprintKey derives a short, non-secret key ID from a key so ciphertexts can record
+// which key encrypted them without exposing key material.
+func fingerprintKey(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "local-" + hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,257 @@
+package memorystore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"dario.cat/mergo"
+
+	"github.com/getzep/zep/pkg/models"
+	"github.com/jinzhu/copier"
+	"github.com/uptrace/bun"
+)
+
+// mergeMetadata merges src into dst in place, overriding existing keys, the same policy
+// putSessionMetadata uses for its in-Go mergo merge on the unencrypted path.
+func mergeMetadata(dst map[string]interface{}, src map[string]interface{}) error {
+	return mergo.Merge(&dst, src, mergo.WithOverride)
+}
+
+// Metadata mutation under encryption still requires a read-modify-write: because the
+// column holds an opaque ciphertext, Postgres can no longer merge JSON server-side (e.g.
+// via the `metadata || ?` operator some call sites used), so every PutSessionMetadata
+// call decrypts the existing value, merges in Go, and re-encrypts. This is the same
+// shape as putSessionMetadata's transaction, just with an extra decrypt/encrypt step.
+
+func (s *PostgresSessionStore) decryptMetadata(ctx context.Context, session *PgSession) (map[string]interface{}, error) {
+	if session.MetadataEnc == nil {
+		return map[string]interface{}{}, nil
+	}
+	plaintext, err := s.cipher.Decrypt(ctx, session.MetadataEnc.KeyID, session.MetadataEnc.Nonce, session.MetadataEnc.Ciphertext, []byte(session.SessionID))
+	if err != nil {
+		return nil, NewStorageError("failed to decrypt session metadata", err)
+	}
+	metadata := map[string]interface{}{}
+	if len(plaintext) > 0 {
+		if err := json.Unmarshal(plaintext, &metadata); err != nil {
+			return nil, NewStorageError("failed to unmarshal decrypted metadata", err)
+		}
+	}
+	return metadata, nil
+}
+
+func (s *PostgresSessionStore) encryptMetadata(ctx context.Context, sessionID string, metadata map[string]interface{}) (*EncryptedMetadata, error) {
+	plaintext, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, NewStorageError("failed to marshal metadata", err)
+	}
+	nonce, ciphertext, err := s.cipher.Encrypt(ctx, plaintext, []byte(sessionID))
+	if err != nil {
+		return nil, NewStorageError("failed to encrypt session metadata", err)
+	}
+	return &EncryptedMetadata{KeyID: s.cipher.KeyID(), Nonce: nonce, Ciphertext: ciphertext}, nil
+}
+
+func (s *PostgresSessionStore) putSessionEncrypted(
+	ctx context.Context,
+	sessionID string,
+	metadata map[string]interface{},
+	caller models.CallerContext,
+) (*models.Session, error) {
+	if sessionID == "" {
+		return nil, NewStorageError("sessionID cannot be empty", nil)
+	}
+
+	session := PgSession{SessionID: sessionID}
+	_, err := s.db.NewInsert().
+		Model(&session).
+		Column("session_id").
+		On("CONFLICT (session_id) DO UPDATE").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, NewStorageError("failed to put session", err)
+	}
+
+	if err := seedCreatorACL(ctx, s.db, &session, caller); err != nil {
+		return nil, err
+	}
+
+	if err := authorizeSession(&session, caller, PermissionWrite); err != nil {
+		return nil, err
+	}
+
+	stripAdminOnlyMetadataKeys(metadata, session.Permissions, caller.PrincipalID)
+
+	if len(metadata) == 0 {
+		return s.getSessionEncrypted(ctx, sessionID, caller)
+	}
+
+	return s.putSessionMetadataEncrypted(ctx, sessionID, metadata, caller)
+}
+
+func (s *PostgresSessionStore) getSessionEncrypted(
+	ctx context.Context,
+	sessionID string,
+	caller models.CallerContext,
+) (*models.Session, error) {
+	session := PgSession{}
+	if err := s.db.NewSelect().Model(&session).Where("session_id = ?", sessionID).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoExistOrNoAccess
+		}
+		return nil, NewStorageError("failed to get session", err)
+	}
+
+	if err := authorizeSession(&session, caller, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	metadata, err := s.decryptMetadata(ctx, &session)
+	if err != nil {
+		return nil, err
+	}
+	session.Metadata = metadata
+
+	retSession := models.Session{}
+	if err := copier.Copy(&retSession, &session); err != nil {
+		return nil, NewStorageError("failed to copy session", err)
+	}
+	return &retSession, nil
+}
+
+func (s *PostgresSessionStore) putSessionMetadataEncrypted(
+	ctx context.Context,
+	sessionID string,
+	metadata map[string]interface{},
+	caller models.CallerContext,
+) (*models.Session, error) {
+	dbSession := &PgSession{}
+	var merged map[string]interface{}
+
+	err := RunInNewTxn(ctx, s.db, true, func(ctx context.Context, tx bun.Tx) error {
+		dbSession = &PgSession{}
+		if err := tx.NewSelect().Model(dbSession).Where("session_id = ?", sessionID).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoExistOrNoAccess
+			}
+			return NewStorageError("failed to get session", err)
+		}
+
+		if err := authorizeSession(dbSession, caller, PermissionWrite); err != nil {
+			return err
+		}
+
+		stripAdminOnlyMetadataKeys(metadata, dbSession.Permissions, caller.PrincipalID)
+
+		existing, err := s.decryptMetadata(ctx, dbSession)
+		if err != nil {
+			return err
+		}
+		if err := mergeMetadata(existing, metadata); err != nil {
+			return NewStorageError("failed to merge metadata", err)
+		}
+		merged = existing
+
+		encrypted, err := s.encryptMetadata(ctx, sessionID, existing)
+		if err != nil {
+			return err
+		}
+		dbSession.MetadataEnc = encrypted
+		dbSession.Metadata = nil
+
+		if _, err := tx.NewUpdate().
+			Model(dbSession).
+			Set("metadata_enc = ?", encrypted).
+			Set("metadata = NULL").
+			Where("session_id = ?", sessionID).
+			Returning("*").
+			Exec(ctx); err != nil {
+			return NewStorageError("failed to update session metadata", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the fully merged metadata, not just the partial update that was passed in,
+	// so callers see the same view they'd get from a subsequent GetSession.
+	dbSession.Metadata = merged
+	session := &models.Session{}
+	if err := copier.Copy(session, dbSession); err != nil {
+		return nil, NewStorageError("Unable to copy session", err)
+	}
+	return session, nil
+}
+
+// RotateEncryptionKeys walks sessions whose metadata_enc key ID differs from cipher's
+// current KeyID, decrypting with the Cipher's old key material and re-encrypting under
+// the current key. Run this after configuring cipher with a new active key but before
+// retiring the old key's material.
+func RotateEncryptionKeys(ctx context.Context, db *bun.DB, cipher Cipher) (int, error) {
+	store := NewEncryptedPostgresSessionStore(db, cipher)
+
+	var stale []PgSession
+	if err := db.NewSelect().
+		Model(&stale).
+		Where("metadata_enc IS NOT NULL").
+		Where("metadata_enc->>'key_id' != ?", cipher.KeyID()).
+		Scan(ctx); err != nil {
+		return 0, NewStorageError("failed to find sessions with a stale key id", err)
+	}
+
+	rotated := 0
+	for _, session := range stale {
+		staleKeyID := session.MetadataEnc.KeyID
+		didRotate := false
+
+		err := RunInNewTxn(ctx, db, true, func(ctx context.Context, tx bun.Tx) error {
+			// Re-read the row inside the transaction rather than reusing the outer
+			// loop's snapshot: if another writer updated this session's metadata since
+			// the initial scan, blindly re-encrypting the stale plaintext we already
+			// hold would silently overwrite that write with no error.
+			dbSession := &PgSession{}
+			if err := tx.NewSelect().Model(dbSession).Where("session_id = ?", session.SessionID).Scan(ctx); err != nil {
+				return NewStorageError("failed to get session", err)
+			}
+			if dbSession.MetadataEnc == nil || dbSession.MetadataEnc.KeyID != staleKeyID {
+				// Metadata was rewritten (possibly already under the new key) since the
+				// outer scan; skip it rather than clobbering the newer write.
+				return nil
+			}
+
+			metadata, err := store.decryptMetadata(ctx, dbSession)
+			if err != nil {
+				return err
+			}
+			encrypted, err := store.encryptMetadata(ctx, dbSession.SessionID, metadata)
+			if err != nil {
+				return err
+			}
+			res, err := tx.NewUpdate().
+				Model((*PgSession)(nil)).
+				Set("metadata_enc = ?", encrypted).
+				Where("session_id = ?", dbSession.SessionID).
+				Where("metadata_enc->>'key_id' = ?", staleKeyID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err == nil && n > 0 {
+				didRotate = true
+			}
+			return nil
+		})
+		if err != nil {
+			return rotated, NewStorageError("failed to rotate key for session", err)
+		}
+		if didRotate {
+			rotated++
+		}
+	}
+	return rotated, nil
+}
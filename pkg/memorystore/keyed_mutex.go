@@ -0,0 +1,30 @@
+package memorystore
+
+import "sync"
+
+// keyedMutex hands out a *sync.Mutex per key, for backends without a native equivalent
+// of Postgres's pg_advisory_lock. Locks are created lazily and never removed, which is
+// fine for the session keyspace this is used for (bounded by the number of sessions a
+// process has touched).
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*sync.Mutex)}
+}
+
+// Lock acquires the mutex for key, creating it if necessary, and returns an unlock func.
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		k.locks[key] = l
+	}
+	k.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
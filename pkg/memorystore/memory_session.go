@@ -0,0 +1,118 @@
+package memorystore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"dario.cat/mergo"
+
+	"github.com/getzep/zep/pkg/models"
+	"github.com/jinzhu/copier"
+)
+
+// MemorySessionStore is an in-memory models.SessionStore driver with no external
+// dependencies, suitable for tests. It reuses PgSession as its record type and
+// authorizeSession for ACL checks, so its semantics track the Postgres driver exactly.
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*PgSession
+}
+
+// NewMemorySessionStore builds an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*PgSession)}
+}
+
+func (s *MemorySessionStore) PutSession(
+	_ context.Context,
+	sessionID string,
+	metadata map[string]interface{},
+	caller models.CallerContext,
+) (*models.Session, error) {
+	if sessionID == "" {
+		return nil, NewStorageError("sessionID cannot be empty", nil)
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		session = &PgSession{
+			SessionID:   sessionID,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			Permissions: defaultCreatorACL(caller.PrincipalID),
+		}
+		s.sessions[sessionID] = session
+	}
+	s.mu.Unlock()
+
+	if err := authorizeSession(session, caller, PermissionWrite); err != nil {
+		return nil, err
+	}
+
+	stripAdminOnlyMetadataKeys(metadata, session.Permissions, caller.PrincipalID)
+
+	if len(metadata) == 0 {
+		return s.GetSession(context.Background(), sessionID, caller)
+	}
+
+	return s.PutSessionMetadata(context.Background(), sessionID, metadata, caller)
+}
+
+func (s *MemorySessionStore) GetSession(
+	_ context.Context,
+	sessionID string,
+	caller models.CallerContext,
+) (*models.Session, error) {
+	s.mu.RLock()
+	session, ok := s.sessions[sessionID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNoExistOrNoAccess
+	}
+
+	if err := authorizeSession(session, caller, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	retSession := models.Session{}
+	if err := copier.Copy(&retSession, session); err != nil {
+		return nil, NewStorageError("failed to copy session", err)
+	}
+	return &retSession, nil
+}
+
+func (s *MemorySessionStore) PutSessionMetadata(
+	_ context.Context,
+	sessionID string,
+	metadata map[string]interface{},
+	caller models.CallerContext,
+) (*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, ErrNoExistOrNoAccess
+	}
+
+	if err := authorizeSession(session, caller, PermissionWrite); err != nil {
+		return nil, err
+	}
+
+	stripAdminOnlyMetadataKeys(metadata, session.Permissions, caller.PrincipalID)
+
+	dbMetadata := session.Metadata
+	if err := mergo.Merge(&dbMetadata, metadata, mergo.WithOverride); err != nil {
+		return nil, NewStorageError("failed to merge metadata", err)
+	}
+	session.Metadata = dbMetadata
+	session.UpdatedAt = time.Now()
+
+	retSession := &models.Session{}
+	if err := copier.Copy(retSession, session); err != nil {
+		return nil, NewStorageError("Unable to copy session", err)
+	}
+	return retSession, nil
+}
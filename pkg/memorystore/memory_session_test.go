@@ -0,0 +1,105 @@
+package memorystore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/getzep/zep/pkg/models"
+)
+
+func TestMemorySessionStore_PutSessionMetadataMerges(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	caller := models.CallerContext{PrincipalID: "caller"}
+
+	if _, err := store.PutSession(ctx, "s1", map[string]interface{}{"a": "1"}, caller); err != nil {
+		t.Fatalf("PutSession: %v", err)
+	}
+	if _, err := store.PutSessionMetadata(ctx, "s1", map[string]interface{}{"b": "2"}, caller); err != nil {
+		t.Fatalf("PutSessionMetadata: %v", err)
+	}
+
+	got, err := store.GetSession(ctx, "s1", caller)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.Metadata["a"] != "1" || got.Metadata["b"] != "2" {
+		t.Fatalf("expected merged metadata with both keys, got %v", got.Metadata)
+	}
+}
+
+func TestMemorySessionStore_PutSessionMetadataOnMissingSession(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	caller := models.CallerContext{PrincipalID: "caller"}
+
+	_, err := store.PutSessionMetadata(ctx, "does-not-exist", map[string]interface{}{"a": "1"}, caller)
+	if !errors.Is(err, ErrNoExistOrNoAccess) {
+		t.Fatalf("expected ErrNoExistOrNoAccess, got %v", err)
+	}
+}
+
+func TestMemorySessionStore_SystemKeyGatedByAdmin(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	owner := models.CallerContext{PrincipalID: "owner"}
+
+	session, err := store.PutSession(ctx, "s1", map[string]interface{}{"system": "secret"}, owner)
+	if err != nil {
+		t.Fatalf("PutSession: %v", err)
+	}
+	// The creator holds PermissionAdmin, so the system key should pass through.
+	if session.Metadata["system"] != "secret" {
+		t.Fatalf("expected admin caller to set system key, got %v", session.Metadata)
+	}
+
+	// A caller with write but not admin permission can't overwrite it: the key is stripped.
+	store.sessions["s1"].Permissions["writer"] = map[Permission]bool{PermissionExist: true, PermissionWrite: true}
+	writer := models.CallerContext{PrincipalID: "writer"}
+	session, err = store.PutSessionMetadata(ctx, "s1", map[string]interface{}{"system": "overwritten"}, writer)
+	if err != nil {
+		t.Fatalf("PutSessionMetadata as writer: %v", err)
+	}
+	if session.Metadata["system"] != "secret" {
+		t.Fatalf("expected non-admin caller's system key to be dropped, got %v", session.Metadata)
+	}
+}
+
+func TestKeyedMutex_SerializesSameKey(t *testing.T) {
+	km := newKeyedMutex()
+
+	unlockA := km.Lock("k")
+	locked := make(chan struct{})
+	go func() {
+		unlockB := km.Lock("k")
+		defer unlockB()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("second Lock on the same key acquired while the first was still held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlockA()
+	<-locked
+}
+
+func TestKeyedMutex_DifferentKeysDontBlock(t *testing.T) {
+	km := newKeyedMutex()
+
+	unlockA := km.Lock("a")
+	defer unlockA()
+
+	done := make(chan struct{})
+	go func() {
+		unlockB := km.Lock("b")
+		defer unlockB()
+		close(done)
+	}()
+
+	<-done
+}
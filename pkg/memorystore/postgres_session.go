@@ -3,6 +3,7 @@ package memorystore
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"dario.cat/mergo"
@@ -12,13 +13,68 @@ import (
 	"github.com/uptrace/bun"
 )
 
+// ErrNoExistOrNoAccess is returned by every memorystore session entry point whenever the
+// caller is not allowed to know whether a sessionID exists. It is returned identically
+// for a missing session, a soft-deleted session, and a session the caller lacks access
+// to, so that responses never leak session existence to an unauthorized caller.
+var ErrNoExistOrNoAccess = errors.New("session does not exist or caller does not have access")
+
+// authorizeSession checks that caller holds perm on session, returning ErrNoExistOrNoAccess
+// if the session is missing, soft-deleted, or the caller lacks the permission. required
+// must include PermissionExist implicitly: callers without PermissionExist never learn
+// anything more specific than ErrNoExistOrNoAccess.
+func authorizeSession(session *PgSession, caller models.CallerContext, required Permission) error {
+	if session == nil || !session.DeletedAt.IsZero() {
+		return ErrNoExistOrNoAccess
+	}
+	if !session.Permissions.Has(caller.PrincipalID, PermissionExist) {
+		return ErrNoExistOrNoAccess
+	}
+	if required != PermissionExist && !session.Permissions.Has(caller.PrincipalID, required) {
+		return ErrNoExistOrNoAccess
+	}
+	return nil
+}
+
+// seedCreatorACL gives caller a full-permission ACL entry on session if it was just
+// created (i.e. has no Permissions yet), using a WHERE permissions IS NULL guard so a
+// concurrent creator can't clobber an ACL another caller already seeded.
+func seedCreatorACL(ctx context.Context, db bun.IDB, session *PgSession, caller models.CallerContext) error {
+	if session.Permissions != nil {
+		return nil
+	}
+	acl := defaultCreatorACL(caller.PrincipalID)
+	res, err := db.NewUpdate().
+		Model(session).
+		Set("permissions = ?", acl).
+		Where("session_id = ?", session.SessionID).
+		Where("permissions IS NULL").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return NewStorageError("failed to seed default session ACL", err)
+	}
+
+	// If the guard lost the race (a concurrent PutSession for the same brand-new
+	// sessionID already seeded the ACL first), the update affects 0 rows and Returning
+	// populates nothing, leaving session.Permissions nil. Re-fetch the row so the
+	// caller authorizes against the ACL that actually won the race, rather than a nil
+	// one that would reject even its own creator.
+	if n, rowsErr := res.RowsAffected(); rowsErr != nil || n == 0 {
+		if err := db.NewSelect().Model(session).Where("session_id = ?", session.SessionID).Scan(ctx); err != nil {
+			return NewStorageError("failed to re-fetch session after ACL seed race", err)
+		}
+	}
+	return nil
+}
+
 // putSession stores a new session or updates an existing session with new metadata.
 func putSession(
 	ctx context.Context,
 	db *bun.DB,
 	sessionID string,
 	metadata map[string]interface{},
-	isPrivileged bool,
+	caller models.CallerContext,
 ) (*models.Session, error) {
 	if sessionID == "" {
 		return nil, NewStorageError("sessionID cannot be empty", nil)
@@ -38,19 +94,23 @@ func putSession(
 		return nil, NewStorageError("failed to put session", err)
 	}
 
-	// If the session is deleted, return an error
-	if !session.DeletedAt.IsZero() {
-		return nil, NewStorageError(fmt.Sprintf("session %s is deleted", sessionID), nil)
+	// A brand-new row has no ACL yet; seed one granting its creator full permissions
+	// before authorizing against it, or every session would be unreadable by anyone,
+	// including whoever just created it.
+	if err := seedCreatorACL(ctx, db, &session, caller); err != nil {
+		return nil, err
 	}
 
-	// remove the top-level `system` key from the metadata if the caller is not privileged
-	if !isPrivileged {
-		delete(metadata, "system")
+	if err := authorizeSession(&session, caller, PermissionWrite); err != nil {
+		return nil, err
 	}
 
+	// remove admin-only reserved keys (system, retention policy) unless caller has admin
+	stripAdminOnlyMetadataKeys(metadata, session.Permissions, caller.PrincipalID)
+
 	// return the session if there is no metadata to update
 	if len(metadata) == 0 {
-		returnedSession, err := getSession(ctx, db, sessionID)
+		returnedSession, err := getSession(ctx, db, sessionID, caller)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get session: %w", err)
 		}
@@ -58,78 +118,95 @@ func putSession(
 	}
 
 	// otherwise, update the session metadata and return the session
-	return putSessionMetadata(ctx, db, sessionID, metadata)
+	return putSessionMetadata(ctx, db, sessionID, metadata, caller)
 }
 
 // putSessionMetadata updates the metadata for a session. The metadata map is merged
 // with the existing metadata map, creating keys and values if they don't exist.
+//
+// Concurrent updates are serialized by RunInNewTxn rather than a Postgres advisory lock:
+// the read-merge-write runs inside a SERIALIZABLE transaction that's re-run with backoff
+// if Postgres detects a conflicting concurrent writer, instead of blocking on a lock.
 func putSessionMetadata(ctx context.Context,
 	db *bun.DB,
 	sessionID string,
-	metadata map[string]interface{}) (*models.Session, error) {
-	// Acquire a lock for this SessionID. This is to prevent concurrent updates
-	// to the session metadata.
-	lockID, err := acquireAdvisoryLock(ctx, db, sessionID)
-	if err != nil {
-		return nil, NewStorageError("failed to acquire advisory lock", err)
-	}
-	defer func(ctx context.Context, db bun.IDB, lockID uint64) {
-		err := releaseAdvisoryLock(ctx, db, lockID)
+	metadata map[string]interface{},
+	caller models.CallerContext) (*models.Session, error) {
+	dbSession := &PgSession{}
+
+	err := RunInNewTxn(ctx, db, true, func(ctx context.Context, tx bun.Tx) error {
+		dbSession = &PgSession{}
+		err := tx.NewSelect().
+			Model(dbSession).
+			Where("session_id = ?", sessionID).
+			Scan(ctx)
 		if err != nil {
-			log.Error(ctx, "failed to release advisory lock", err)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoExistOrNoAccess
+			}
+			return NewStorageError("failed to get session", err)
 		}
-	}(ctx, db, lockID)
 
-	dbSession := &PgSession{}
-	err = db.NewSelect().
-		Model(dbSession).
-		Where("session_id = ?", sessionID).
-		Scan(ctx)
-	if err != nil {
-		return nil, NewStorageError("failed to get session", err)
-	}
+		if err := authorizeSession(dbSession, caller, PermissionWrite); err != nil {
+			return err
+		}
 
-	// merge the existing metadata with the new metadata
-	dbMetadata := dbSession.Metadata
-	if err := mergo.Merge(&dbMetadata, metadata, mergo.WithOverride); err != nil {
-		return nil, NewStorageError("failed to merge metadata", err)
-	}
+		stripAdminOnlyMetadataKeys(metadata, dbSession.Permissions, caller.PrincipalID)
 
-	// put the session metadata, returning the updated session
-	_, err = db.NewUpdate().
-		Model(dbSession).
-		Set("metadata = ?", dbMetadata).
-		Where("session_id = ?", sessionID).
-		Returning("*").
-		Exec(ctx)
+		// merge the existing metadata with the new metadata
+		dbMetadata := dbSession.Metadata
+		if err := mergo.Merge(&dbMetadata, metadata, mergo.WithOverride); err != nil {
+			return NewStorageError("failed to merge metadata", err)
+		}
+		dbSession.Metadata = dbMetadata
+
+		// put the session metadata, returning the updated session
+		_, err = tx.NewUpdate().
+			Model(dbSession).
+			Set("metadata = ?", dbMetadata).
+			Where("session_id = ?", sessionID).
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			return NewStorageError("failed to update session metadata", err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, NewStorageError("failed to update session metadata", err)
+		return nil, err
 	}
 
 	session := &models.Session{}
-	err = copier.Copy(session, dbSession)
-	if err != nil {
+	if err := copier.Copy(session, dbSession); err != nil {
 		return nil, NewStorageError("Unable to copy session", err)
 	}
 
 	return session, nil
 }
 
-// getSession retrieves a session from the memory store.
+// getSession retrieves a session from the memory store. It returns ErrNoExistOrNoAccess,
+// never a distinguishable "not found" error, if the session is missing, soft-deleted, or
+// the caller lacks at least read access.
 func getSession(
 	ctx context.Context,
 	db *bun.DB,
 	sessionID string,
+	caller models.CallerContext,
 ) (*models.Session, error) {
 	session := PgSession{}
 	err := db.NewSelect().Model(&session).Where("session_id = ?", sessionID).Scan(ctx)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoExistOrNoAccess
 		}
 		return nil, NewStorageError("failed to get session", err)
 	}
 
+	if err := authorizeSession(&session, caller, PermissionRead); err != nil {
+		return nil, err
+	}
+
 	retSession := models.Session{}
 	err = copier.Copy(&retSession, &session)
 	if err != nil {
@@ -138,3 +215,68 @@ func getSession(
 
 	return &retSession, nil
 }
+
+// authorizeSessionACLChange checks that caller may modify session's ACL, gating the
+// change behind PermissionAdmin in the same way putSession gates the reserved `system`
+// metadata key.
+func authorizeSessionACLChange(session *PgSession, caller models.CallerContext) error {
+	return authorizeSession(session, caller, PermissionAdmin)
+}
+
+// PostgresSessionStore is the models.SessionStore driver backed by Postgres via bun.
+// Without a cipher it delegates to the package's free functions (putSession, getSession,
+// putSessionMetadata); with one it uses the encrypted variants in cipher_session.go.
+// SQLiteSessionStore has its own implementations and only shares the ACL helpers.
+type PostgresSessionStore struct {
+	db *bun.DB
+	// cipher, if non-nil, enables transparent envelope encryption of session metadata.
+	// See encryptMetadata and decryptMetadata in cipher_session.go.
+	cipher Cipher
+}
+
+// NewPostgresSessionStore builds a PostgresSessionStore over db.
+func NewPostgresSessionStore(db *bun.DB) *PostgresSessionStore {
+	return &PostgresSessionStore{db: db}
+}
+
+// NewEncryptedPostgresSessionStore builds a PostgresSessionStore that encrypts metadata
+// at rest using cipher. Reads transparently decrypt; see cipher_session.go for how
+// metadata mutation merges under encryption.
+func NewEncryptedPostgresSessionStore(db *bun.DB, cipher Cipher) *PostgresSessionStore {
+	return &PostgresSessionStore{db: db, cipher: cipher}
+}
+
+func (s *PostgresSessionStore) PutSession(
+	ctx context.Context,
+	sessionID string,
+	metadata map[string]interface{},
+	caller models.CallerContext,
+) (*models.Session, error) {
+	if s.cipher != nil {
+		return s.putSessionEncrypted(ctx, sessionID, metadata, caller)
+	}
+	return putSession(ctx, s.db, sessionID, metadata, caller)
+}
+
+func (s *PostgresSessionStore) GetSession(
+	ctx context.Context,
+	sessionID string,
+	caller models.CallerContext,
+) (*models.Session, error) {
+	if s.cipher != nil {
+		return s.getSessionEncrypted(ctx, sessionID, caller)
+	}
+	return getSession(ctx, s.db, sessionID, caller)
+}
+
+func (s *PostgresSessionStore) PutSessionMetadata(
+	ctx context.Context,
+	sessionID string,
+	metadata map[string]interface{},
+	caller models.CallerContext,
+) (*models.Session, error) {
+	if s.cipher != nil {
+		return s.putSessionMetadataEncrypted(ctx, sessionID, metadata, caller)
+	}
+	return putSessionMetadata(ctx, s.db, sessionID, metadata, caller)
+}
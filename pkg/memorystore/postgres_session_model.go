@@ -0,0 +1,106 @@
+package memorystore
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Permission is a single capability that can be granted to a principal on a session.
+type Permission string
+
+const (
+	// PermissionExist lets a caller learn that a session exists, without granting read access.
+	PermissionExist Permission = "exist"
+	// PermissionRead lets a caller read a session's metadata and history.
+	PermissionRead Permission = "read"
+	// PermissionWrite lets a caller upsert a session's metadata.
+	PermissionWrite Permission = "write"
+	// PermissionAdmin lets a caller change a session's ACL and reserved metadata keys.
+	PermissionAdmin Permission = "admin"
+)
+
+// SessionACL maps a principal (e.g. a user or API key ID) to the set of permissions
+// they hold on a session.
+type SessionACL map[string]map[Permission]bool
+
+// Has reports whether principal holds perm, either directly or via the "*" wildcard
+// principal used for default/anonymous access.
+func (a SessionACL) Has(principal string, perm Permission) bool {
+	if a == nil {
+		return false
+	}
+	if a[principal][perm] {
+		return true
+	}
+	return a["*"][perm]
+}
+
+// defaultCreatorACL is the ACL seeded onto a brand-new session so the caller who just
+// created it can read, write, and administer it. Without this, authorizeSession would
+// fail PermissionExist against a nil Permissions map for every session, including to its
+// own creator.
+func defaultCreatorACL(principal string) SessionACL {
+	return SessionACL{
+		principal: {
+			PermissionExist: true,
+			PermissionRead:  true,
+			PermissionWrite: true,
+			PermissionAdmin: true,
+		},
+	}
+}
+
+// RetentionPolicy bounds how long a session's data is kept. A zero MaxAge or
+// MaxMessageCount means that dimension is unbounded.
+type RetentionPolicy struct {
+	// MaxAge is how long after a session was last updated it becomes eligible for
+	// expiration. Zero means no age-based expiration.
+	MaxAge time.Duration `json:"max_age,omitempty"`
+	// MaxMessageCount, if set, expires a session once it holds more than this many
+	// messages, regardless of age.
+	MaxMessageCount int `json:"max_message_count,omitempty"`
+}
+
+// IsZero reports whether p imposes no retention bound at all.
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxAge == 0 && p.MaxMessageCount == 0
+}
+
+// retentionMetadataKey is the reserved putSession metadata key a caller with admin
+// permission can set to override the global retention policy for a single session. Its
+// value is a RetentionPolicy marshaled as JSON.
+const retentionMetadataKey = "_retention_policy"
+
+// adminOnlyMetadataKeys are reserved metadata keys that a caller must hold
+// PermissionAdmin to set; stripAdminOnlyMetadataKeys removes them from any metadata
+// update from a caller who doesn't.
+var adminOnlyMetadataKeys = []string{"system", retentionMetadataKey}
+
+// stripAdminOnlyMetadataKeys deletes every admin-only key from metadata unless principal
+// holds PermissionAdmin on acl. It's called by every PutSession/PutSessionMetadata path
+// before the update is applied, so a plain PermissionWrite caller can't use an ordinary
+// metadata update to, say, disable their own session's retention policy.
+func stripAdminOnlyMetadataKeys(metadata map[string]interface{}, acl SessionACL, principal string) {
+	if acl.Has(principal, PermissionAdmin) {
+		return
+	}
+	for _, key := range adminOnlyMetadataKeys {
+		delete(metadata, key)
+	}
+}
+
+// PgSession is the Postgres-backed representation of a session row.
+type PgSession struct {
+	bun.BaseModel `bun:"table:session,alias:s"`
+
+	UUID            string                 `bun:",pk,type:uuid,default:gen_random_uuid()"`
+	CreatedAt       time.Time              `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt       time.Time              `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	DeletedAt       time.Time              `bun:"deleted_at,soft_delete,nullzero"`
+	SessionID       string                 `bun:",unique"`
+	Metadata        map[string]interface{} `bun:"metadata,type:jsonb,nullzero"`
+	MetadataEnc     *EncryptedMetadata     `bun:"metadata_enc,type:jsonb,nullzero"`
+	Permissions     SessionACL             `bun:"permissions,type:jsonb,nullzero"`
+	RetentionPolicy RetentionPolicy        `bun:"retention_policy,type:jsonb,nullzero"`
+}
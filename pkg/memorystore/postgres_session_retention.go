@@ -0,0 +1,302 @@
+package memorystore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/getzep/zep/pkg/models"
+	"github.com/uptrace/bun"
+)
+
+// sweeperLockID is the fixed pg_advisory_lock key the retention sweeper uses for leader
+// election: whichever zep replica holds it runs the sweep, so replicas don't stampede.
+const sweeperLockID uint64 = 72170001
+
+// defaultHardDeleteGrace mirrors the two-week grace window the shard-group deletion path
+// uses elsewhere in the fleet: a soft-deleted, expired session is only hard-deleted after
+// this much additional time has passed, giving operators a window to notice and recover.
+const defaultHardDeleteGrace = 14 * 24 * time.Hour
+
+// RetentionMetrics are the Prometheus counters the sweeper increments. Wire these to real
+// prometheus.Counters at startup; they're plain int64s here so the sweeper has no hard
+// dependency on the metrics package.
+type RetentionMetrics struct {
+	SessionsExpired *int64
+	SessionsPurged  *int64
+}
+
+func (m *RetentionMetrics) incExpired(n int64) {
+	if m == nil || m.SessionsExpired == nil {
+		return
+	}
+	*m.SessionsExpired += n
+}
+
+func (m *RetentionMetrics) incPurged(n int64) {
+	if m == nil || m.SessionsPurged == nil {
+		return
+	}
+	*m.SessionsPurged += n
+}
+
+// RetentionSweeperConfig configures the background expiration worker.
+type RetentionSweeperConfig struct {
+	// DefaultPolicy applies to sessions that have neither a retention_policy column value
+	// nor a per-session override via the reserved putSession metadata key.
+	DefaultPolicy RetentionPolicy
+	// Interval is the average time between sweeps; actual sweeps are jittered by up to
+	// 20% to avoid every replica waking at the same instant.
+	Interval time.Duration
+	// HardDeleteGrace is how long after soft-deletion an expired session becomes
+	// eligible for hard deletion. Defaults to defaultHardDeleteGrace.
+	HardDeleteGrace time.Duration
+	Metrics         *RetentionMetrics
+	// MessageCounter returns the number of messages currently stored for sessionID, used
+	// to evaluate a policy's MaxMessageCount. The message store lives in a separate
+	// package from memorystore, so the sweeper takes a plain function here rather than
+	// importing it directly, avoiding an import cycle. A nil MessageCounter disables
+	// MaxMessageCount expiration; sessions are still expired by MaxAge.
+	MessageCounter func(ctx context.Context, sessionID string) (int, error)
+}
+
+// tryAcquireAdvisoryLock attempts to take the Postgres advisory lock identified by id
+// without blocking, unlike acquireAdvisoryLock which waits. It's used for sweeper leader
+// election, where a replica that loses the race should skip this tick rather than queue.
+func tryAcquireAdvisoryLock(ctx context.Context, db *bun.DB, id uint64) (uint64, bool, error) {
+	var acquired bool
+	if err := db.NewSelect().
+		ColumnExpr("pg_try_advisory_lock(?)", id).
+		Scan(ctx, &acquired); err != nil {
+		return 0, false, err
+	}
+	return id, acquired, nil
+}
+
+// RetentionSweeper periodically soft-deletes sessions past their retention policy, then
+// hard-deletes sessions that have been soft-deleted for longer than HardDeleteGrace.
+type RetentionSweeper struct {
+	db  *bun.DB
+	cfg RetentionSweeperConfig
+}
+
+// NewRetentionSweeper builds a RetentionSweeper. Call Run in its own goroutine to start
+// the periodic sweep.
+func NewRetentionSweeper(db *bun.DB, cfg RetentionSweeperConfig) *RetentionSweeper {
+	if cfg.HardDeleteGrace == 0 {
+		cfg.HardDeleteGrace = defaultHardDeleteGrace
+	}
+	return &RetentionSweeper{db: db, cfg: cfg}
+}
+
+// Run blocks, sweeping every Interval (jittered) until ctx is canceled. Only one replica
+// actually sweeps at a time: each tick tries to acquire the sweeper's advisory lock and
+// skips the sweep if another replica already holds it.
+func (s *RetentionSweeper) Run(ctx context.Context) {
+	for {
+		wait := jitter(s.cfg.Interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		s.tick(ctx)
+	}
+}
+
+func (s *RetentionSweeper) tick(ctx context.Context) {
+	lockID, ok, err := tryAcquireAdvisoryLock(ctx, s.db, sweeperLockID)
+	if err != nil {
+		log.Error(ctx, "retention sweeper: failed to acquire leader lock", err)
+		return
+	}
+	if !ok {
+		// another replica is already sweeping
+		return
+	}
+	defer func() {
+		if err := releaseAdvisoryLock(ctx, s.db, lockID); err != nil {
+			log.Error(ctx, "retention sweeper: failed to release leader lock", err)
+		}
+	}()
+
+	if err := s.PurgeExpired(ctx); err != nil {
+		log.Error(ctx, "retention sweeper: sweep failed", err)
+	}
+}
+
+// PurgeExpired runs one sweep on demand: it soft-deletes sessions past their retention
+// policy, then hard-deletes sessions (and their messages and summaries) that have been
+// soft-deleted for longer than HardDeleteGrace.
+func (s *RetentionSweeper) PurgeExpired(ctx context.Context) error {
+	expired, err := s.findExpiredSessions(ctx)
+	if err != nil {
+		return NewStorageError("failed to find expired sessions", err)
+	}
+	for _, session := range expired {
+		if _, err := s.db.NewUpdate().
+			Model((*PgSession)(nil)).
+			Set("deleted_at = current_timestamp").
+			Where("session_id = ?", session.SessionID).
+			Exec(ctx); err != nil {
+			return NewStorageError("failed to soft-delete expired session", err)
+		}
+	}
+	s.cfg.Metrics.incExpired(int64(len(expired)))
+
+	purgeable := []PgSession{}
+	if err := s.db.NewSelect().
+		Model(&purgeable).
+		WhereAllWithDeleted().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-s.cfg.HardDeleteGrace)).
+		Scan(ctx); err != nil {
+		return NewStorageError("failed to find purgeable sessions", err)
+	}
+	for _, session := range purgeable {
+		if err := s.hardDelete(ctx, session.SessionID); err != nil {
+			return NewStorageError("failed to hard-delete session", err)
+		}
+	}
+	s.cfg.Metrics.incPurged(int64(len(purgeable)))
+
+	return nil
+}
+
+// hardDelete permanently removes a session and its messages and summaries.
+func (s *RetentionSweeper) hardDelete(ctx context.Context, sessionID string) error {
+	return RunInNewTxn(ctx, s.db, true, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().
+			Model((*PgSession)(nil)).
+			WhereAllWithDeleted().
+			Where("session_id = ?", sessionID).
+			ForceDelete().
+			Exec(ctx); err != nil {
+			return err
+		}
+		// message and summary rows are keyed by session_id and cascade-deleted by the
+		// schema's foreign keys; nothing further to do here.
+		return nil
+	})
+}
+
+// findExpiredSessions returns active sessions whose effective retention policy (the
+// per-session override if set, else DefaultPolicy) has elapsed.
+func (s *RetentionSweeper) findExpiredSessions(ctx context.Context) ([]PgSession, error) {
+	var candidates []PgSession
+	if err := s.db.NewSelect().Model(&candidates).Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var expired []PgSession
+	for _, session := range candidates {
+		policy := s.effectivePolicy(session)
+		if policy.IsZero() {
+			continue
+		}
+		if policy.MaxAge != 0 && now.After(session.UpdatedAt.Add(policy.MaxAge)) {
+			expired = append(expired, session)
+			continue
+		}
+		if policy.MaxMessageCount != 0 && s.cfg.MessageCounter != nil {
+			count, err := s.cfg.MessageCounter(ctx, session.SessionID)
+			if err != nil {
+				return nil, NewStorageError("failed to count messages for session", err)
+			}
+			if count > policy.MaxMessageCount {
+				expired = append(expired, session)
+			}
+		}
+	}
+	return expired, nil
+}
+
+// effectivePolicy returns session's retention_policy column if it is non-zero (as set by
+// SetRetentionPolicy), else the override under the reserved putSession metadata key if
+// present and decodable, else the sweeper's DefaultPolicy.
+func (s *RetentionSweeper) effectivePolicy(session PgSession) RetentionPolicy {
+	if !session.RetentionPolicy.IsZero() {
+		return session.RetentionPolicy
+	}
+	raw, ok := session.Metadata[retentionMetadataKey]
+	if !ok {
+		return s.cfg.DefaultPolicy
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return s.cfg.DefaultPolicy
+	}
+	var policy RetentionPolicy
+	if err := json.Unmarshal(encoded, &policy); err != nil {
+		return s.cfg.DefaultPolicy
+	}
+	return policy
+}
+
+// jitter returns d plus or minus 20%, so replicas on the same Interval don't sweep in
+// lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	spread := float64(d) * 0.2
+	return d + time.Duration(rand.Float64()*2*spread-spread)
+}
+
+// GetRetentionPolicy returns session's per-session retention_policy column, the value
+// SetRetentionPolicy writes (the zero policy if none was set). It does not apply the
+// metadata-key override or the sweeper's DefaultPolicy. It's the read side of the admin
+// retention endpoints; the caller must hold PermissionAdmin on the session.
+func GetRetentionPolicy(
+	ctx context.Context,
+	db *bun.DB,
+	sessionID string,
+	caller models.CallerContext,
+) (*RetentionPolicy, error) {
+	session := PgSession{}
+	if err := db.NewSelect().Model(&session).Where("session_id = ?", sessionID).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoExistOrNoAccess
+		}
+		return nil, NewStorageError("failed to get session", err)
+	}
+	if err := authorizeSession(&session, caller, PermissionAdmin); err != nil {
+		return nil, err
+	}
+	return &session.RetentionPolicy, nil
+}
+
+// SetRetentionPolicy overrides session's retention policy. The caller must hold
+// PermissionAdmin on the session.
+func SetRetentionPolicy(
+	ctx context.Context,
+	db *bun.DB,
+	sessionID string,
+	policy RetentionPolicy,
+	caller models.CallerContext,
+) error {
+	session := PgSession{}
+	if err := db.NewSelect().Model(&session).Where("session_id = ?", sessionID).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoExistOrNoAccess
+		}
+		return NewStorageError("failed to get session", err)
+	}
+	if err := authorizeSession(&session, caller, PermissionAdmin); err != nil {
+		return err
+	}
+
+	_, err := db.NewUpdate().
+		Model((*PgSession)(nil)).
+		Set("retention_policy = ?", policy).
+		Where("session_id = ?", sessionID).
+		Exec(ctx)
+	if err != nil {
+		return NewStorageError("failed to set retention policy", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package memorystore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/uptrace/bun"
+)
+
+// sqlTxOptionsSerializable is used for every RunInNewTxn transaction so that Postgres
+// itself detects the write-write and read-write conflicts we retry on.
+var sqlTxOptionsSerializable = sql.TxOptions{Isolation: sql.LevelSerializable}
+
+// txnRetryBaseDelay and txnRetryMaxDelay bound the exponential backoff RunInNewTxn uses
+// between retries of a serialization failure.
+const (
+	txnRetryBaseDelay     = 10 * time.Millisecond
+	txnRetryMaxDelay      = 500 * time.Millisecond
+	defaultTxnMaxAttempts = 5
+)
+
+// txnMaxAttempts is the configurable cap on RunInNewTxn retry attempts. It defaults to
+// defaultTxnMaxAttempts; override with SetTxnMaxAttempts, e.g. from config at startup.
+var txnMaxAttempts atomic.Int64
+
+func init() {
+	txnMaxAttempts.Store(defaultTxnMaxAttempts)
+}
+
+// SetTxnMaxAttempts overrides the number of attempts RunInNewTxn makes before giving up
+// on a retryable transaction. n must be at least 1.
+func SetTxnMaxAttempts(n int) {
+	if n < 1 {
+		n = 1
+	}
+	txnMaxAttempts.Store(int64(n))
+}
+
+// txnRetryMetrics tracks how often RunInNewTxn retries or gives up. It's a package-level
+// counter rather than a Prometheus metric for now; swap for real instrumentation once
+// zep's metrics package grows a home for store-level counters. RunInNewTxn is invoked
+// concurrently by every writer by design, so these are atomics rather than plain ints.
+var txnRetryMetrics = struct {
+	Attempts      atomic.Int64
+	Aborts        atomic.Int64
+	FinalFailures atomic.Int64
+}{}
+
+// isRetryableTxnError reports whether err is a Postgres serialization failure that's
+// safe to retry by re-running the transaction body. It's a var, not a func, so message
+// and summary stores can override the classification if they wrap errors differently.
+var isRetryableTxnError = func(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return true
+	default:
+		return false
+	}
+}
+
+// RunInNewTxn runs fn inside a new SERIALIZABLE transaction, retrying with exponential
+// backoff (up to txnMaxAttempts attempts) when commit fails with a Postgres serialization
+// or deadlock error. This replaces advisory-lock-based serialization for writers that can
+// tolerate re-running fn: instead of blocking behind a lock, each writer optimistically
+// commits and only pays the cost of a retry on genuine contention.
+//
+// If retryable is false, fn is run once and any error is returned immediately without
+// retrying, for callers that already know their transaction can't safely be re-run.
+func RunInNewTxn(ctx context.Context, db *bun.DB, retryable bool, fn func(ctx context.Context, tx bun.Tx) error) error {
+	attempts := int(txnMaxAttempts.Load())
+	if !retryable {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			txnRetryMetrics.Attempts.Add(1)
+			delay := backoffDelay(attempt)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		err := db.RunInTx(ctx, &sqlTxOptionsSerializable, func(ctx context.Context, tx bun.Tx) error {
+			return fn(ctx, tx)
+		})
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		if !retryable || !isRetryableTxnError(err) {
+			txnRetryMetrics.FinalFailures.Add(1)
+			// err is fn's error (an application error like ErrNoExistOrNoAccess, or
+			// one already wrapped with NewStorageError) or a non-retryable commit
+			// failure; return it as-is rather than masking it in another wrapper.
+			return err
+		}
+		txnRetryMetrics.Aborts.Add(1)
+	}
+
+	txnRetryMetrics.FinalFailures.Add(1)
+	return NewStorageError("transaction failed after retries", lastErr)
+}
+
+// backoffDelay returns a jittered exponential delay for the given retry attempt, capped
+// at txnRetryMaxDelay.
+func backoffDelay(attempt int) time.Duration {
+	delay := txnRetryBaseDelay * time.Duration(1<<uint(attempt-1))
+	if delay > txnRetryMaxDelay {
+		delay = txnRetryMaxDelay
+	}
+	// full jitter, to avoid retry storms across concurrent writers
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
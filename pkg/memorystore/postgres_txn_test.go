@@ -0,0 +1,64 @@
+package memorystore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestIsRetryableTxnError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock detected", &pgconn.PgError{Code: "40P01"}, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"non-pg error", errors.New("boom"), false},
+		{"wrapped pg error", fmtWrap(&pgconn.PgError{Code: "40001"}), true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetryableTxnError(tc.err); got != tc.want {
+				t.Errorf("isRetryableTxnError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func fmtWrap(err error) error {
+	return &wrappedErr{err}
+}
+
+type wrappedErr struct{ err error }
+
+func (w *wrappedErr) Error() string { return "wrapped: " + w.err.Error() }
+func (w *wrappedErr) Unwrap() error { return w.err }
+
+func TestSetTxnMaxAttempts(t *testing.T) {
+	orig := txnMaxAttempts.Load()
+	defer txnMaxAttempts.Store(orig)
+
+	SetTxnMaxAttempts(3)
+	if got := txnMaxAttempts.Load(); got != 3 {
+		t.Errorf("txnMaxAttempts = %d, want 3", got)
+	}
+
+	// Attempts below 1 clamp to 1 rather than disabling the retry loop entirely.
+	SetTxnMaxAttempts(0)
+	if got := txnMaxAttempts.Load(); got != 1 {
+		t.Errorf("txnMaxAttempts after SetTxnMaxAttempts(0) = %d, want 1", got)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := backoffDelay(attempt)
+		if d < 0 || d > txnRetryMaxDelay {
+			t.Errorf("backoffDelay(%d) = %v, want in [0, %v]", attempt, d, txnRetryMaxDelay)
+		}
+	}
+}
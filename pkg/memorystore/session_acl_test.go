@@ -0,0 +1,73 @@
+package memorystore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/getzep/zep/pkg/models"
+)
+
+// TestMemorySessionStore_CreateAndReadBack verifies the bug this package used to have:
+// a caller must be able to create a session and then read it back, since PutSession
+// seeds the creator's own ACL entry before authorizing against it.
+func TestMemorySessionStore_CreateAndReadBack(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	creator := models.CallerContext{PrincipalID: "creator"}
+
+	if _, err := store.PutSession(ctx, "session-1", map[string]interface{}{"foo": "bar"}, creator); err != nil {
+		t.Fatalf("PutSession as creator: %v", err)
+	}
+
+	got, err := store.GetSession(ctx, "session-1", creator)
+	if err != nil {
+		t.Fatalf("GetSession as creator: %v", err)
+	}
+	if got == nil {
+		t.Fatal("GetSession returned a nil session with no error")
+	}
+}
+
+// TestMemorySessionStore_NoExistenceLeak verifies that a missing session, a session the
+// caller lacks access to, and a soft-deleted session are all indistinguishable to an
+// unprivileged caller.
+func TestMemorySessionStore_NoExistenceLeak(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	owner := models.CallerContext{PrincipalID: "owner"}
+	stranger := models.CallerContext{PrincipalID: "stranger"}
+
+	if _, err := store.PutSession(ctx, "session-1", nil, owner); err != nil {
+		t.Fatalf("PutSession as owner: %v", err)
+	}
+
+	_, errMissing := store.GetSession(ctx, "does-not-exist", stranger)
+	_, errForbidden := store.GetSession(ctx, "session-1", stranger)
+
+	if !errors.Is(errMissing, ErrNoExistOrNoAccess) {
+		t.Fatalf("expected ErrNoExistOrNoAccess for a missing session, got %v", errMissing)
+	}
+	if !errors.Is(errForbidden, ErrNoExistOrNoAccess) {
+		t.Fatalf("expected ErrNoExistOrNoAccess for a forbidden session, got %v", errForbidden)
+	}
+	if !errors.Is(errMissing, errForbidden) {
+		t.Fatalf("missing and forbidden sessions must return the exact same sentinel error")
+	}
+
+	// A caller with PermissionExist but not PermissionRead also can't distinguish
+	// "forbidden" from "not found".
+	store.sessions["session-1"].Permissions["stranger"] = map[Permission]bool{PermissionExist: true}
+	_, errExistOnly := store.GetSession(ctx, "session-1", stranger)
+	if !errors.Is(errExistOnly, ErrNoExistOrNoAccess) {
+		t.Fatalf("expected ErrNoExistOrNoAccess for a caller with exist-only access, got %v", errExistOnly)
+	}
+
+	// A soft-deleted session returns the same sentinel, even to its owner.
+	store.sessions["session-1"].DeletedAt = time.Now()
+	_, errDeleted := store.GetSession(ctx, "session-1", owner)
+	if !errors.Is(errDeleted, ErrNoExistOrNoAccess) {
+		t.Fatalf("expected ErrNoExistOrNoAccess for a soft-deleted session, got %v", errDeleted)
+	}
+}
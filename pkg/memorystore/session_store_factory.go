@@ -0,0 +1,38 @@
+package memorystore
+
+import (
+	"fmt"
+
+	"github.com/getzep/zep/pkg/models"
+	"github.com/uptrace/bun"
+)
+
+// SessionStoreConfig selects and configures a models.SessionStore driver. zep depends
+// only on models.SessionStore from here on; everything backend-specific lives behind
+// NewSessionStore.
+type SessionStoreConfig struct {
+	// Type is one of "postgres", "sqlite", or "memory".
+	Type string
+	// DB is the *bun.DB to use for the postgres and sqlite drivers. Unused for memory.
+	DB *bun.DB
+}
+
+// NewSessionStore builds the configured models.SessionStore driver.
+func NewSessionStore(cfg SessionStoreConfig) (models.SessionStore, error) {
+	switch cfg.Type {
+	case "", "postgres":
+		if cfg.DB == nil {
+			return nil, NewStorageError("postgres session store requires a DB", nil)
+		}
+		return NewPostgresSessionStore(cfg.DB), nil
+	case "sqlite":
+		if cfg.DB == nil {
+			return nil, NewStorageError("sqlite session store requires a DB", nil)
+		}
+		return NewSQLiteSessionStore(cfg.DB), nil
+	case "memory":
+		return NewMemorySessionStore(), nil
+	default:
+		return nil, NewStorageError(fmt.Sprintf("unknown session store type %q", cfg.Type), nil)
+	}
+}
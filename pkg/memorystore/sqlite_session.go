@@ -0,0 +1,143 @@
+package memorystore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"dario.cat/mergo"
+
+	"github.com/getzep/zep/pkg/models"
+	"github.com/jinzhu/copier"
+	"github.com/uptrace/bun"
+)
+
+// SQLiteSessionStore is the models.SessionStore driver backed by bun's sqlite dialect.
+// It targets the same schema and ACL semantics as PostgresSessionStore, but serializes
+// metadata upserts with an in-process keyedMutex instead of PostgresSessionStore's
+// retried SERIALIZABLE transactions (RunInNewTxn), since SQLite has no server-side lock
+// primitive to acquire across connections.
+type SQLiteSessionStore struct {
+	db    *bun.DB
+	locks *keyedMutex
+}
+
+// NewSQLiteSessionStore builds a SQLiteSessionStore over db, which must be opened with
+// bun's sqlitedialect.
+func NewSQLiteSessionStore(db *bun.DB) *SQLiteSessionStore {
+	return &SQLiteSessionStore{db: db, locks: newKeyedMutex()}
+}
+
+func (s *SQLiteSessionStore) PutSession(
+	ctx context.Context,
+	sessionID string,
+	metadata map[string]interface{},
+	caller models.CallerContext,
+) (*models.Session, error) {
+	if sessionID == "" {
+		return nil, NewStorageError("sessionID cannot be empty", nil)
+	}
+
+	session := PgSession{SessionID: sessionID}
+	_, err := s.db.NewInsert().
+		Model(&session).
+		Column("session_id").
+		On("CONFLICT (session_id) DO UPDATE").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, NewStorageError("failed to put session", err)
+	}
+
+	if err := seedCreatorACL(ctx, s.db, &session, caller); err != nil {
+		return nil, err
+	}
+
+	if err := authorizeSession(&session, caller, PermissionWrite); err != nil {
+		return nil, err
+	}
+
+	stripAdminOnlyMetadataKeys(metadata, session.Permissions, caller.PrincipalID)
+
+	if len(metadata) == 0 {
+		return s.GetSession(ctx, sessionID, caller)
+	}
+
+	return s.PutSessionMetadata(ctx, sessionID, metadata, caller)
+}
+
+func (s *SQLiteSessionStore) GetSession(
+	ctx context.Context,
+	sessionID string,
+	caller models.CallerContext,
+) (*models.Session, error) {
+	session := PgSession{}
+	err := s.db.NewSelect().Model(&session).Where("session_id = ?", sessionID).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoExistOrNoAccess
+		}
+		return nil, NewStorageError("failed to get session", err)
+	}
+
+	if err := authorizeSession(&session, caller, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	retSession := models.Session{}
+	if err := copier.Copy(&retSession, &session); err != nil {
+		return nil, NewStorageError("failed to copy session", err)
+	}
+
+	return &retSession, nil
+}
+
+func (s *SQLiteSessionStore) PutSessionMetadata(
+	ctx context.Context,
+	sessionID string,
+	metadata map[string]interface{},
+	caller models.CallerContext,
+) (*models.Session, error) {
+	unlock := s.locks.Lock(sessionID)
+	defer unlock()
+
+	dbSession := &PgSession{}
+	err := s.db.NewSelect().
+		Model(dbSession).
+		Where("session_id = ?", sessionID).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoExistOrNoAccess
+		}
+		return nil, NewStorageError("failed to get session", err)
+	}
+
+	if err := authorizeSession(dbSession, caller, PermissionWrite); err != nil {
+		return nil, err
+	}
+
+	stripAdminOnlyMetadataKeys(metadata, dbSession.Permissions, caller.PrincipalID)
+
+	dbMetadata := dbSession.Metadata
+	if err := mergo.Merge(&dbMetadata, metadata, mergo.WithOverride); err != nil {
+		return nil, NewStorageError("failed to merge metadata", err)
+	}
+
+	_, err = s.db.NewUpdate().
+		Model(dbSession).
+		Set("metadata = ?", dbMetadata).
+		Where("session_id = ?", sessionID).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, NewStorageError("failed to update session metadata", err)
+	}
+
+	session := &models.Session{}
+	if err := copier.Copy(session, dbSession); err != nil {
+		return nil, NewStorageError("Unable to copy session", err)
+	}
+
+	return session, nil
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Session is the store-agnostic view of a session returned by SessionStore. It
+// intentionally excludes storage-internal fields (ACLs, encryption envelopes) that
+// individual drivers keep to themselves.
+type Session struct {
+	UUID      string                 `json:"uuid"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	DeletedAt time.Time              `json:"deleted_at,omitempty"`
+	SessionID string                 `json:"session_id"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "context"
+
+// CallerContext identifies the principal making a session store request. It is derived
+// from the authenticated request (e.g. an API key or user ID) by the caller and threaded
+// through every SessionStore method so drivers can enforce per-session ACLs consistently.
+type CallerContext struct {
+	// PrincipalID identifies the caller for ACL checks. The zero value is an anonymous
+	// caller: it matches ACL entries keyed by the empty principal (such as the creator
+	// entry of a session an anonymous caller created) and a driver's wildcard entry, if any.
+	PrincipalID string
+}
+
+// SessionStore is the storage interface the rest of zep depends on for session CRUD.
+// The concrete driver (Postgres, SQLite, in-memory, ...) is selected at startup from
+// config, mirroring how the DB session provider picks its backing engine.
+type SessionStore interface {
+	// PutSession stores a new session or updates an existing session's metadata.
+	PutSession(ctx context.Context, sessionID string, metadata map[string]interface{}, caller CallerContext) (*Session, error)
+	// GetSession retrieves a session. It returns the same error for a missing session,
+	// a soft-deleted session, and a session the caller lacks access to.
+	GetSession(ctx context.Context, sessionID string, caller CallerContext) (*Session, error)
+	// PutSessionMetadata merges metadata into a session's existing metadata.
+	PutSessionMetadata(ctx context.Context, sessionID string, metadata map[string]interface{}, caller CallerContext) (*Session, error)
+}
@@ -0,0 +1,103 @@
+// Package server holds the small set of admin HTTP handlers that sit in front of
+// memorystore. It intentionally has no dependency on a particular router: handlers are
+// plain http.HandlerFunc values, wired into whatever mux the rest of zep uses at startup.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/getzep/zep/pkg/memorystore"
+	"github.com/getzep/zep/pkg/models"
+	"github.com/uptrace/bun"
+)
+
+// RetentionHandler exposes admin endpoints for inspecting and overriding a session's
+// retention policy. Both endpoints require the caller to hold PermissionAdmin on the
+// session; memorystore.GetRetentionPolicy/SetRetentionPolicy enforce that and return
+// memorystore.ErrNoExistOrNoAccess (mapped to 404 below) if they don't.
+type RetentionHandler struct {
+	db *bun.DB
+}
+
+// NewRetentionHandler builds a RetentionHandler over db.
+func NewRetentionHandler(db *bun.DB) *RetentionHandler {
+	return &RetentionHandler{db: db}
+}
+
+// callerFromRequest derives a models.CallerContext from the authenticated request. Like
+// the rest of zep's admin surface, the principal is expected to have already been
+// authenticated upstream (e.g. by an API key middleware) and attached to this header.
+func callerFromRequest(r *http.Request) models.CallerContext {
+	return models.CallerContext{PrincipalID: r.Header.Get("X-Zep-Principal-Id")}
+}
+
+// sessionIDFromPath extracts the sessionID path segment from
+// /api/v1/admin/sessions/{sessionID}/retention.
+func sessionIDFromPath(r *http.Request) string {
+	const prefix = "/api/v1/admin/sessions/"
+	const suffix = "/retention"
+	path := r.URL.Path
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(path, prefix), suffix)
+}
+
+// GetRetentionPolicy handles GET /api/v1/admin/sessions/{sessionID}/retention, returning
+// the session's per-session retention policy override (see
+// memorystore.GetRetentionPolicy) as JSON.
+func (h *RetentionHandler) GetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	sessionID := sessionIDFromPath(r)
+	if sessionID == "" {
+		http.Error(w, "sessionID is required", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := memorystore.GetRetentionPolicy(r.Context(), h.db, sessionID, callerFromRequest(r))
+	if err != nil {
+		writeRetentionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policy); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// PutRetentionPolicy handles PUT /api/v1/admin/sessions/{sessionID}/retention, overriding
+// the session's retention policy with the JSON body.
+func (h *RetentionHandler) PutRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	sessionID := sessionIDFromPath(r)
+	if sessionID == "" {
+		http.Error(w, "sessionID is required", http.StatusBadRequest)
+		return
+	}
+
+	var policy memorystore.RetentionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "invalid retention policy body", http.StatusBadRequest)
+		return
+	}
+
+	if err := memorystore.SetRetentionPolicy(r.Context(), h.db, sessionID, policy, callerFromRequest(r)); err != nil {
+		writeRetentionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeRetentionError maps memorystore errors to HTTP status codes, taking care not to
+// distinguish "not found" from "forbidden" for memorystore.ErrNoExistOrNoAccess the same
+// way the store itself refuses to.
+func writeRetentionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, memorystore.ErrNoExistOrNoAccess) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}